@@ -2,45 +2,142 @@ package spm
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 
+	"gopkg.in/yaml.v2"
+
+	"gitlab.spimageworks.com/dev-group/dev-ops/spm/expr"
 	"gitlab.spimageworks.com/dev-group/dev-ops/spm/internal/spfs"
 )
 
 const (
 	defaultBuildCommand = "bash build.sh"
+	defaultFormat       = "spfs"
 )
 
-// BuildVariants builds all of the default variants defined for the given spec
+// BuildVariants builds all of the default variants defined for the given
+// spec, producing a Handle for every (variant, format) pair plus one for
+// each subpackage. A variant whose spfs tag already exists is not rebuilt
+// and yields that single spfs Handle instead.
 func BuildVariants(spec *Spec) ([]Handle, error) {
 
 	variants := spec.Build.Variants
+	if len(variants) == 0 && spec.Build.Matrix {
+		expanded, err := ExpandMatrix(spec.Options, spec.Build.Exclude, spec.Build.Include)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand build matrix: %w", err)
+		}
+		variants = expanded
+	}
 	if len(variants) == 0 {
 		variants = []OptionMap{OptionMap{}}
 	}
 
-	handles := make([]Handle, len(variants))
+	handles := make([]Handle, 0, len(variants))
 	for i, options := range variants {
-		h, err := Build(spec, options)
+		digest, _, _, err := effectiveDigest(spec, options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve variant %d options: %w", i, err)
+		}
+
+		existing, err := findExistingVariant(spec, digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for existing build of variant %d [%s]: %w", i, digest, err)
+		}
+		if existing != nil {
+			handles = append(handles, existing)
+			continue
+		}
+
+		variantHandles, err := Build(spec, options)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to build variant %d [%s]: %w", i, options.Digest(), err)
+			return nil, fmt.Errorf("Failed to build variant %d [%s]: %w", i, digest, err)
 		}
-		handles[i] = h
+		handles = append(handles, variantHandles...)
 	}
 	return handles, nil
 }
 
-// Build executes the build process for a package spec with the given build options
-func Build(spec *Spec, options OptionMap) (Handle, error) {
+// effectiveDigest evaluates spec's depends/provides when-expressions
+// against options and returns the resulting variant digest (options
+// folded together with the filtered, applicable depends) along with
+// the filtered depends/provides themselves. Both BuildVariants and
+// Build call this so they always agree on which tag a given variant
+// commits to.
+func effectiveDigest(spec *Spec, options OptionMap) (digest string, depends, provides []Spec, err error) {
+
+	env := expr.Env(options)
+
+	depends, err = filterConditional(spec.Depends, env)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to evaluate dependency when-expressions: %w", err)
+	}
+	provides, err = filterConditional(spec.Provides, env)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to evaluate provides when-expressions: %w", err)
+	}
+
+	depDigestInputs := make([]string, len(depends))
+	for i, dep := range depends {
+		depDigestInputs[i] = dep.Package.String()
+	}
+	return options.Digest(depDigestInputs...), depends, provides, nil
+}
+
+// findExistingVariant checks spfs for a tag already committed for this
+// exact variant digest, letting BuildVariants skip a redundant rebuild
+// when the matrix is regenerated but nothing about the variant changed
+func findExistingVariant(spec *Spec, digest string) (Handle, error) {
+
+	ident := Ident{
+		Name:    spec.Package.Name,
+		Version: spec.Package.Version,
+		Release: spec.Package.Release.WithVariant(digest),
+	}
+	tag := "spm/pkg/" + ident.String()
+
+	tags, err := spfs.ListTags(tag)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		if t == tag {
+			return NewSpFSHandle(spec, tag), nil
+		}
+	}
+	return nil, nil
+}
+
+// Build executes the build process for a package spec with the given
+// build options, packaging the result into every format configured on
+// the spec's build.formats (or just spfs, if none are given)
+func Build(spec *Spec, options OptionMap) ([]Handle, error) {
 
 	cmdString := spec.Build.Command
 	if cmdString == "" {
 		cmdString = defaultBuildCommand
 	}
 
-	release := options.Digest()
-	fmt.Printf("|--| building: %s/%s |--|\n", spec.Package.String(), release)
+	env := expr.Env(options)
+
+	digest, filteredDepends, filteredProvides, err := effectiveDigest(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the effective spec only carries the depends/provides that
+	// actually apply to this variant's options, and folds the variant's
+	// digest into its release so that variants with the same options
+	// but different effective dependencies commit to distinct tags
+	effectiveSpec := *spec
+	effectiveSpec.Depends = filteredDepends
+	effectiveSpec.Provides = filteredProvides
+	effectiveSpec.Package.Release = spec.Package.Release.WithVariant(digest)
+
+	fmt.Printf("|--| building: %s |--|\n", effectiveSpec.Package.String())
 	for _, opt := range spec.Options {
 		value, given := options[opt.Package.Name]
 		if !given {
@@ -49,23 +146,53 @@ func Build(spec *Spec, options OptionMap) (Handle, error) {
 		fmt.Printf("%s: %s\n", opt.Package.Name, value)
 	}
 
-	// TODO: get build dependencies
-	deps := make([]string, len(spec.Options))
-	for i, dep := range spec.Options {
-		// TODO: what if the dep.Package already has a version/release?
-		tag := fmt.Sprintf("spm/pkg/%s/%s", dep.Package.Name, options[dep.Package.Name])
-		deps[i] = tag
+	requested := make([]Ident, len(filteredDepends))
+	for i, dep := range filteredDepends {
+		requested[i] = dep.Package
+	}
+	resolver := NewResolver(SpFSPackageSource{})
+	plan, err := resolver.Resolve(requested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve dependencies: %w", err)
 	}
 
-	err := spfs.ResetEditable(deps...)
+	deps := make([]string, len(plan.InstallDeps))
+	for i, ident := range plan.InstallDeps {
+		deps[i] = "spm/pkg/" + ident.String()
+	}
+
+	err = spfs.ResetEditable(deps...)
 	if err != nil {
 		return nil, err
 	}
 
+	// builds install into an isolated DESTDIR rather than the live "/" so
+	// that every packager (spfs, tar, rpm) has an actual tree to commit
+	// or archive instead of the whole host filesystem
+	destDir, err := ioutil.TempDir("", "spm-install-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create install root: %w", err)
+	}
+	defer os.RemoveAll(destDir)
+
 	cmd := exec.Command("sh", "-c", cmdString)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "DESTDIR="+destDir)
+
+	for _, e := range spec.Build.Env {
+		if e.When != "" {
+			ok, err := expr.Evaluate(e.When, env)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate when-expression for env var %s: %w", e.Name, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", e.Name, e.Value))
+	}
 
 	fmt.Printf("|--| %s |--| \n", cmd)
 	err = cmd.Run()
@@ -76,11 +203,111 @@ func Build(spec *Spec, options OptionMap) (Handle, error) {
 	// TODO: check that there are file changes
 	// TODO: check that there are no overwritten files
 
-	tag := "spm/pkg/" + spec.Package.String()
-	err = spfs.CommitLayer(tag)
+	// stash the effective spec inside the built layer so that a later
+	// SpFSPackageSource.Candidates lookup can recover this package's
+	// real depends/provides, not just its identifier
+	if err := writeManifest(&effectiveSpec, destDir); err != nil {
+		return nil, fmt.Errorf("failed to write package manifest: %w", err)
+	}
+
+	formats := spec.Build.Formats
+	if len(formats) == 0 {
+		formats = []string{defaultFormat}
+	}
+
+	handles := make([]Handle, 0, len(formats)+len(spec.Subpackages))
+	for _, format := range formats {
+		packager, err := Get(format)
+		if err != nil {
+			return nil, fmt.Errorf("cannot package %s: %w", effectiveSpec.Package.String(), err)
+		}
+		handle, err := packager.Package(&effectiveSpec, options, destDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to package %s as %s: %w", effectiveSpec.Package.String(), format, err)
+		}
+		handles = append(handles, handle)
+	}
+
+	subpackageHandles, err := splitSubpackages(&effectiveSpec, digest, destDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to commit package data to spfs: %w", err)
+		return nil, err
 	}
+	handles = append(handles, subpackageHandles...)
+
+	return handles, nil
+}
+
+// splitSubpackages commits one spfs layer per subpackage declared on the
+// spec, selecting each one's files out of the just-built tree (rooted at
+// root, the build's DESTDIR) with its glob patterns and linking the
+// result back to the source package
+func splitSubpackages(source *Spec, digest string, root string) ([]Handle, error) {
+
+	handles := make([]Handle, len(source.Subpackages))
+	for i, sub := range source.Subpackages {
 
-	return NewSpFSHandle(spec, tag), nil
+		ident := subpackageIdent(sub, digest)
+		subSpec := Spec{
+			Package:  ident,
+			Depends:  sub.Depends,
+			Provides: sub.Provides,
+		}
+
+		tag := "spm/pkg/" + ident.String()
+		if err := spfs.CommitLayerFiles(root, sub.Files, tag); err != nil {
+			return nil, fmt.Errorf("failed to package subpackage %s: %w", sub.Package.Name, err)
+		}
+
+		handles[i] = NewSpFSSubpackageHandle(&subSpec, tag, source.Package)
+	}
+	return handles, nil
+}
+
+// subpackageIdent folds digest into sub's declared release the same way
+// effectiveSpec.Package.Release is derived for the main package, so the
+// tag committed here matches what Handle.Spec()/Handle.Url() report and
+// a later `depends: <name>/<version>` can Satisfies() against it
+func subpackageIdent(sub Subpackage, digest string) Ident {
+	return Ident{
+		Name:    sub.Package.Name,
+		Version: sub.Package.Version,
+		Release: sub.Package.Release.WithVariant(digest),
+	}
+}
+
+// writeManifest serializes spec to yaml and writes it to manifestPath
+// under root (the build's DESTDIR), so it is picked up as part of the
+// layer that gets committed alongside the rest of the build's output
+func writeManifest(spec *Spec, root string) error {
+
+	content, err := yaml.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, manifestPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// filterConditional returns the subset of specs whose When expression
+// (if any) evaluates to true against env, failing early on the first
+// malformed or unresolvable expression
+func filterConditional(specs []Spec, env expr.Env) ([]Spec, error) {
+
+	filtered := make([]Spec, 0, len(specs))
+	for _, s := range specs {
+		if s.When != "" {
+			ok, err := expr.Evaluate(s.When, env)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Package.Name, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered, nil
 }
@@ -0,0 +1,42 @@
+package spm
+
+import "testing"
+
+func TestSubpackageIdentFoldsDigestIntoDeclaredRelease(t *testing.T) {
+
+	sub := Subpackage{
+		Package: Ident{
+			Name:    "libfoo-dev",
+			Version: ParseVersion("1.0.0"),
+			Release: ParseRelease("r2"),
+		},
+	}
+
+	ident := subpackageIdent(sub, "abc123")
+
+	if ident.Name != "libfoo-dev" {
+		t.Errorf("expected name to be preserved, got %q", ident.Name)
+	}
+	if ident.Version.String() != "1.0.0" {
+		t.Errorf("expected declared version to survive, got %s", ident.Version)
+	}
+	if ident.Release.String() != "r2.abc123" {
+		t.Errorf("expected digest folded into the declared release, got %s", ident.Release)
+	}
+}
+
+func TestSubpackageIdentWithNoDeclaredRelease(t *testing.T) {
+
+	sub := Subpackage{
+		Package: Ident{
+			Name:    "libfoo-dev",
+			Version: ParseVersion("1.0.0"),
+		},
+	}
+
+	ident := subpackageIdent(sub, "abc123")
+
+	if ident.Release.String() != "abc123" {
+		t.Errorf("expected the digest alone as the release, got %s", ident.Release)
+	}
+}
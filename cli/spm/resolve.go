@@ -34,5 +34,20 @@ func runResolve(cmd *cobra.Command, args []string) error {
 	for _, spec := range specs {
 		fmt.Println("requested: ", spec)
 	}
+
+	resolver := spm.NewResolver(spm.SpFSPackageSource{})
+	plan, err := resolver.Resolve(specs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("transitive dependencies:")
+	for _, ident := range plan.TransitiveDeps {
+		fmt.Println(" -", ident)
+	}
+	fmt.Println("install plan:")
+	for _, ident := range plan.InstallDeps {
+		fmt.Println(" -", ident)
+	}
 	return nil
 }
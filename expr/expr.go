@@ -0,0 +1,309 @@
+// Package expr implements the small expression language used to gate
+// conditional options, dependencies and build flags on the current set
+// of build options (eg. `python.abi == "cp39" && !static`).
+//
+// Supported syntax:
+//
+//	identifiers   python.abi
+//	literals      "cp39", true, false, 3
+//	comparison    ==, !=, <, <=, >, >=
+//	logical       &&, ||, !
+//	membership    x in [a, b, c]
+//	grouping      ( ... )
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Env provides the values that identifiers in an expression resolve to
+type Env map[string]string
+
+// Evaluate parses and evaluates the given expression against env,
+// returning an error if the expression is malformed, references an
+// identifier that is not present in env, or does not evaluate to a bool
+func Evaluate(source string, env Env) (bool, error) {
+
+	node, err := parse(source)
+	if err != nil {
+		return false, err
+	}
+
+	if err := checkIdentifiers(node, env); err != nil {
+		return false, err
+	}
+
+	value, err := node.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expr: expression does not evaluate to a bool: %s", source)
+	}
+	return b, nil
+}
+
+// node is a parsed expression that can be evaluated against an Env
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+func parse(source string) (node, error) {
+
+	p := &parser{lexer: newLexer(source)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected token %q", p.tok.text)
+	}
+	return n, nil
+}
+
+// checkIdentifiers walks the whole parsed tree up front and fails if any
+// identifier is missing from env, so that an unresolved identifier on
+// the short-circuited side of a `&&`/`||` (eg. `static || typo == "x"`)
+// is still caught, regardless of evaluation order.
+func checkIdentifiers(n node, env Env) error {
+	switch t := n.(type) {
+	case *identNode:
+		if _, ok := env[t.name]; !ok {
+			return fmt.Errorf("expr: unresolved identifier: %s", t.name)
+		}
+	case *notOp:
+		return checkIdentifiers(t.operand, env)
+	case *binaryOp:
+		if err := checkIdentifiers(t.left, env); err != nil {
+			return err
+		}
+		return checkIdentifiers(t.right, env)
+	case *compareOp:
+		if err := checkIdentifiers(t.left, env); err != nil {
+			return err
+		}
+		return checkIdentifiers(t.right, env)
+	case *inOp:
+		if err := checkIdentifiers(t.target, env); err != nil {
+			return err
+		}
+		for _, item := range t.items {
+			if err := checkIdentifiers(item, env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// --- ast ---
+
+type literal struct{ value interface{} }
+
+func (n *literal) eval(env Env) (interface{}, error) { return n.value, nil }
+
+type identNode struct{ name string }
+
+func (n *identNode) eval(env Env) (interface{}, error) {
+	value, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("expr: unresolved identifier: %s", n.name)
+	}
+	return value, nil
+}
+
+type notOp struct{ operand node }
+
+func (n *notOp) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := coerceBool(value)
+	if !ok {
+		return nil, fmt.Errorf("expr: '!' requires a bool operand, got %T", value)
+	}
+	return !b, nil
+}
+
+type binaryOp struct {
+	op          tokenKind
+	left, right node
+}
+
+func (n *binaryOp) eval(env Env) (interface{}, error) {
+
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	lb, ok := coerceBool(left)
+	if !ok {
+		return nil, fmt.Errorf("expr: '&&'/'||' require bool operands, got %T", left)
+	}
+	if n.op == tokOr && lb {
+		return true, nil
+	}
+	if n.op == tokAnd && !lb {
+		return false, nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := coerceBool(right)
+	if !ok {
+		return nil, fmt.Errorf("expr: '&&'/'||' require bool operands, got %T", right)
+	}
+	if n.op == tokOr {
+		return lb || rb, nil
+	}
+	return lb && rb, nil
+}
+
+type compareOp struct {
+	op          tokenKind
+	left, right node
+}
+
+func (n *compareOp) eval(env Env) (interface{}, error) {
+
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case tokEq:
+		return valuesEqual(left, right), nil
+	case tokNeq:
+		return !valuesEqual(left, right), nil
+	}
+
+	switch l := left.(type) {
+	case int:
+		r, ok := right.(int)
+		if !ok {
+			return nil, fmt.Errorf("expr: cannot compare int to %T", right)
+		}
+		return compareInts(n.op, l, r), nil
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("expr: cannot compare string to %T", right)
+		}
+		return compareStrings(n.op, l, r), nil
+	default:
+		return nil, fmt.Errorf("expr: %T does not support ordering comparisons", left)
+	}
+}
+
+func compareInts(op tokenKind, l, r int) bool {
+	switch op {
+	case tokLt:
+		return l < r
+	case tokLe:
+		return l <= r
+	case tokGt:
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func compareStrings(op tokenKind, l, r string) bool {
+	switch op {
+	case tokLt:
+		return l < r
+	case tokLe:
+		return l <= r
+	case tokGt:
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+type inOp struct {
+	target node
+	items  []node
+}
+
+func (n *inOp) eval(env Env) (interface{}, error) {
+
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	targetStr, ok := coerceString(target)
+	if !ok {
+		return nil, fmt.Errorf("expr: 'in' requires a comparable left-hand operand, got %T", target)
+	}
+
+	for _, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if itemStr, ok := coerceString(value); ok && itemStr == targetStr {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// valuesEqual compares two evaluated values for equality. Options are
+// always string-valued, so a bool/int literal is coerced to its string
+// form to let `static == true` match a string option value of "true".
+func valuesEqual(left, right interface{}) bool {
+	ls, lok := coerceString(left)
+	rs, rok := coerceString(right)
+	if lok && rok {
+		return ls == rs
+	}
+	return left == right
+}
+
+// coerceBool converts a bare identifier's string option value into a
+// bool when it appears in a boolean position (eg. `!static` or
+// `static && ...`), since options are always string-valued. Only the
+// literal strings "true"/"false" coerce; anything else is left as a
+// type mismatch for the caller to report.
+func coerceBool(v interface{}) (bool, bool) {
+	switch t := v.(type) {
+	case bool:
+		return t, true
+	case string:
+		switch t {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func coerceString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case bool:
+		return strconv.FormatBool(t), true
+	case int:
+		return strconv.Itoa(t), true
+	}
+	return "", false
+}
@@ -0,0 +1,71 @@
+package expr
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+
+	env := Env{
+		"python.abi": "cp39",
+		"static":     "true",
+	}
+
+	cases := []struct {
+		source string
+		want   bool
+	}{
+		{`python.abi == "cp39"`, true},
+		{`python.abi == "cp38"`, false},
+		{`python.abi != "cp38"`, true},
+		{`python.abi == "cp39" && static == true`, true},
+		{`python.abi == "cp38" || static == true`, true},
+		{`!(python.abi == "cp38")`, true},
+		{`python.abi == "cp39" && !static`, false},
+		{`static && python.abi == "cp39"`, true},
+		{`python.abi in ["cp38", "cp39"]`, true},
+		{`python.abi in ["cp27", "cp38"]`, false},
+		{`3 < 4`, true},
+		{`3 >= 4`, false},
+	}
+
+	for _, c := range cases {
+		got, err := Evaluate(c.source, env)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.source, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.source, c.want, got)
+		}
+	}
+}
+
+func TestEvaluateUnresolvedIdentifier(t *testing.T) {
+
+	_, err := Evaluate(`missing == "x"`, Env{})
+	if err == nil {
+		t.Fatal("expected an error for an unresolved identifier")
+	}
+}
+
+func TestEvaluateUnresolvedIdentifierShortCircuited(t *testing.T) {
+
+	env := Env{"static": "true"}
+
+	cases := []string{
+		`static || typo == "x"`,
+		`!static && typo == "x"`,
+	}
+	for _, c := range cases {
+		_, err := Evaluate(c, env)
+		if err == nil {
+			t.Errorf("%s: expected an error for the unresolved identifier even though it's short-circuited", c)
+		}
+	}
+}
+
+func TestEvaluateSyntaxError(t *testing.T) {
+
+	_, err := Evaluate(`python.abi ==`, Env{"python.abi": "cp39"})
+	if err == nil {
+		t.Fatal("expected a syntax error")
+	}
+}
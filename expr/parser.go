@@ -0,0 +1,382 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokInt
+	tokTrue
+	tokFalse
+	tokAnd
+	tokOr
+	tokNot
+	tokEq
+	tokNeq
+	tokLt
+	tokLe
+	tokGt
+	tokGe
+	tokIn
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lexer scans a raw expression source into tokens, tracking byte
+// positions so parse errors can point at the offending character
+type lexer struct {
+	src string
+	pos int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src}
+}
+
+func (l *lexer) next() (token, error) {
+
+	l.skipSpace()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case c == '(':
+		l.pos++
+		return token{tokLParen, "(", start}, nil
+	case c == ')':
+		l.pos++
+		return token{tokRParen, ")", start}, nil
+	case c == '[':
+		l.pos++
+		return token{tokLBracket, "[", start}, nil
+	case c == ']':
+		l.pos++
+		return token{tokRBracket, "]", start}, nil
+	case c == ',':
+		l.pos++
+		return token{tokComma, ",", start}, nil
+	case c == '!':
+		if l.peek(1) == '=' {
+			l.pos += 2
+			return token{tokNeq, "!=", start}, nil
+		}
+		l.pos++
+		return token{tokNot, "!", start}, nil
+	case c == '=' && l.peek(1) == '=':
+		l.pos += 2
+		return token{tokEq, "==", start}, nil
+	case c == '<':
+		if l.peek(1) == '=' {
+			l.pos += 2
+			return token{tokLe, "<=", start}, nil
+		}
+		l.pos++
+		return token{tokLt, "<", start}, nil
+	case c == '>':
+		if l.peek(1) == '=' {
+			l.pos += 2
+			return token{tokGe, ">=", start}, nil
+		}
+		l.pos++
+		return token{tokGt, ">", start}, nil
+	case c == '&' && l.peek(1) == '&':
+		l.pos += 2
+		return token{tokAnd, "&&", start}, nil
+	case c == '|' && l.peek(1) == '|':
+		l.pos += 2
+		return token{tokOr, "||", start}, nil
+	case c == '"':
+		return l.lexString(start)
+	case isDigit(c):
+		return l.lexNumber(start)
+	case isIdentStart(c):
+		return l.lexIdent(start)
+	default:
+		return token{}, l.errorf(start, "unexpected character %q", c)
+	}
+}
+
+func (l *lexer) lexString(start int) (token, error) {
+	l.pos++
+	for l.pos < len(l.src) && l.src[l.pos] != '"' {
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{}, l.errorf(start, "unterminated string literal")
+	}
+	text := l.src[start+1 : l.pos]
+	l.pos++
+	return token{tokString, text, start}, nil
+}
+
+func (l *lexer) lexNumber(start int) (token, error) {
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	return token{tokInt, l.src[start:l.pos], start}, nil
+}
+
+func (l *lexer) lexIdent(start int) (token, error) {
+	for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+		l.pos++
+	}
+	text := l.src[start:l.pos]
+	switch text {
+	case "in":
+		return token{tokIn, text, start}, nil
+	case "true":
+		return token{tokTrue, text, start}, nil
+	case "false":
+		return token{tokFalse, text, start}, nil
+	default:
+		return token{tokIdent, text, start}, nil
+	}
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', '\n', '\r':
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+offset]
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("expr: %s (at position %d)", fmt.Sprintf(format, args...), pos)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.' || c == '-'
+}
+
+// parser is a simple recursive-descent parser over the expression
+// grammar, from lowest to highest precedence: || , && , ! , comparison
+// , membership (`in`) , primary
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("expr: %s (at position %d)", fmt.Sprintf(format, args...), p.tok.pos)
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if p.tok.kind != kind {
+		return p.errorf("unexpected token %q", p.tok.text)
+	}
+	return p.advance()
+}
+
+func (p *parser) parseOr() (node, error) {
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryOp{op: tokOr, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryOp{op: tokAnd, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+
+	if p.tok.kind == tokNot {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notOp{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+
+	left, err := p.parseMembership()
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.tok.kind {
+	case tokEq, tokNeq, tokLt, tokLe, tokGt, tokGe:
+		op := p.tok.kind
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseMembership()
+		if err != nil {
+			return nil, err
+		}
+		return &compareOp{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseMembership() (node, error) {
+
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokIn {
+		return left, nil
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokLBracket); err != nil {
+		return nil, err
+	}
+
+	var items []node
+	for p.tok.kind != tokRBracket {
+		item, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.tok.kind != tokComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(tokRBracket); err != nil {
+		return nil, err
+	}
+	return &inOp{target: left, items: items}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+
+	switch p.tok.kind {
+	case tokIdent:
+		name := p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &identNode{name: name}, nil
+
+	case tokString:
+		value := p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &literal{value: value}, nil
+
+	case tokInt:
+		n, convErr := strconv.Atoi(p.tok.text)
+		if convErr != nil {
+			return nil, p.errorf("invalid integer literal %q", p.tok.text)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &literal{value: n}, nil
+
+	case tokTrue:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &literal{value: true}, nil
+
+	case tokFalse:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &literal{value: false}, nil
+
+	case tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	default:
+		return nil, p.errorf("unexpected token %q", p.tok.text)
+	}
+}
@@ -7,15 +7,25 @@ import "fmt"
 type Handle interface {
 	Spec() *Spec
 	Url() string
+	// Parent returns the identifier of the source package this handle
+	// was split from, or nil if it is not a subpackage
+	Parent() *Ident
 }
 
 type SpFSHandle struct {
-	spec *Spec
-	ref  string
+	spec   *Spec
+	ref    string
+	parent *Ident
 }
 
 func NewSpFSHandle(spec *Spec, ref string) *SpFSHandle {
-	return &SpFSHandle{spec, ref}
+	return &SpFSHandle{spec: spec, ref: ref}
+}
+
+// NewSpFSSubpackageHandle creates a handle for a binary subpackage split
+// out of the given parent source package
+func NewSpFSSubpackageHandle(spec *Spec, ref string, parent Ident) *SpFSHandle {
+	return &SpFSHandle{spec: spec, ref: ref, parent: &parent}
 }
 
 func (h SpFSHandle) Spec() *Spec {
@@ -26,6 +36,38 @@ func (h SpFSHandle) Url() string {
 	return "spfs:/" + h.ref
 }
 
+func (h SpFSHandle) Parent() *Ident {
+	return h.parent
+}
+
 func (h SpFSHandle) String() string {
 	return fmt.Sprintf("%s | %s", h.spec.Package, h.Url())
 }
+
+// FileHandle represents a package whose artifact was packaged as a
+// regular file on disk, eg. a tar archive or an rpm package
+type FileHandle struct {
+	spec   *Spec
+	path   string
+	parent *Ident
+}
+
+func NewFileHandle(spec *Spec, path string) *FileHandle {
+	return &FileHandle{spec: spec, path: path}
+}
+
+func (h FileHandle) Spec() *Spec {
+	return h.spec
+}
+
+func (h FileHandle) Url() string {
+	return "file://" + h.path
+}
+
+func (h FileHandle) Parent() *Ident {
+	return h.parent
+}
+
+func (h FileHandle) String() string {
+	return fmt.Sprintf("%s | %s", h.spec.Package, h.Url())
+}
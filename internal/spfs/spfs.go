@@ -1,6 +1,7 @@
 package spfs
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os/exec"
@@ -51,12 +52,6 @@ func CommitPlatform(tags ...string) error {
 
 }
 
-func CommitLayer(tags ...string) error {
-
-	return Commit("platform", tags...)
-
-}
-
 func Commit(kind string, tags ...string) error {
 
 	fullArgs := []string{
@@ -71,6 +66,65 @@ func Commit(kind string, tags ...string) error {
 
 }
 
+// CommitLayerFiles commits only the files under root matching one of the
+// given glob patterns as a new layer, tagged with the given tags. This is
+// used to split a single build tree into multiple binary subpackages.
+// With no globs, every file under root is committed.
+func CommitLayerFiles(root string, globs []string, tags ...string) error {
+
+	fullArgs := []string{"commit", "layer", "--root", root}
+	for _, tag := range tags {
+		fullArgs = append(fullArgs, "--tag", tag)
+	}
+	for _, glob := range globs {
+		fullArgs = append(fullArgs, "--only", glob)
+	}
+
+	cmd := exec.Command("spfs", fullArgs...)
+	return getError(cmd.CombinedOutput())
+}
+
+// ReadFile returns the contents of the file at path inside the given
+// spfs reference (a tag or digest), by running `cat` in a throwaway
+// runtime layered on top of it.
+func ReadFile(ref, path string) ([]byte, error) {
+
+	cmd := exec.Command("spfs", "run", ref, "--", "cat", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, getError(stderr.Bytes(), err)
+	}
+	return out, nil
+}
+
+// ListTags returns every known spfs tag beginning with the given prefix,
+// eg. "spm/pkg/mypackage"
+func ListTags(prefix string) ([]string, error) {
+
+	cmd := exec.Command("spfs", "tags", "ls", prefix)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if wrapped := getError(out, err); !errors.Is(wrapped, ErrUnknownTag) {
+			return nil, wrapped
+		}
+		// no tag has ever been committed under this prefix - that's a
+		// normal "nothing built yet" result, not a failure
+		return nil, nil
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	tags := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			tags = append(tags, line)
+		}
+	}
+	return tags, nil
+}
+
 func getError(out []byte, err error) error {
 
 	if err == nil {
@@ -0,0 +1,107 @@
+package spm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExpandMatrix computes the cartesian product of each option's declared
+// Values (or Range), then drops any combination matching one of the
+// exclude overlays and appends the include overlays verbatim - mirroring
+// a GitHub Actions build matrix.
+func ExpandMatrix(options []Spec, exclude, include []OptionMap) ([]OptionMap, error) {
+
+	names := make([]string, 0, len(options))
+	values := make([][]string, 0, len(options))
+	for _, opt := range options {
+		vals, err := opt.matrixValues()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opt.Package.Name, err)
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		names = append(names, opt.Package.Name)
+		values = append(values, vals)
+	}
+
+	variants := []OptionMap{{}}
+	for i, name := range names {
+		next := make([]OptionMap, 0, len(variants)*len(values[i]))
+		for _, base := range variants {
+			for _, value := range values[i] {
+				combo := make(OptionMap, len(base)+1)
+				for k, v := range base {
+					combo[k] = v
+				}
+				combo[name] = value
+				next = append(next, combo)
+			}
+		}
+		variants = next
+	}
+
+	filtered := make([]OptionMap, 0, len(variants))
+	for _, variant := range variants {
+		if !matchesAnyOverlay(variant, exclude) {
+			filtered = append(filtered, variant)
+		}
+	}
+
+	return append(filtered, include...), nil
+}
+
+func matchesAnyOverlay(variant OptionMap, overlays []OptionMap) bool {
+	for _, overlay := range overlays {
+		if matchesOverlay(variant, overlay) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesOverlay(variant, overlay OptionMap) bool {
+	for key, value := range overlay {
+		if variant[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixValues returns the set of values this option expands to in a
+// build matrix, preferring an explicit Range over Values if both are set
+func (spec Spec) matrixValues() ([]string, error) {
+	if spec.Range != "" {
+		return expandRange(spec.Range)
+	}
+	return spec.Values, nil
+}
+
+// expandRange parses an inclusive integer range of the form "min-max"
+func expandRange(source string) ([]string, error) {
+
+	parts := strings.SplitN(source, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid range %q, expected format 'min-max'", source)
+	}
+
+	min, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range %q: %w", source, err)
+	}
+	max, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range %q: %w", source, err)
+	}
+	if max < min {
+		return nil, fmt.Errorf("invalid range %q: max is less than min", source)
+	}
+
+	values := make([]string, 0, max-min+1)
+	for i := min; i <= max; i++ {
+		values = append(values, strconv.Itoa(i))
+	}
+	return values, nil
+}
@@ -0,0 +1,124 @@
+package spm
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestExpandMatrixCartesianProduct(t *testing.T) {
+
+	options := []Spec{
+		{Package: mustIdent(t, "python.abi"), Values: []string{"cp38", "cp39"}},
+		{Package: mustIdent(t, "static"), Values: []string{"true", "false"}},
+	}
+
+	variants, err := ExpandMatrix(options, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(variants) != 4 {
+		t.Fatalf("expected 4 variants from a 2x2 matrix, got %d: %v", len(variants), variants)
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		seen[v["python.abi"]+"/"+v["static"]] = true
+	}
+	for _, want := range []string{"cp38/true", "cp38/false", "cp39/true", "cp39/false"} {
+		if !seen[want] {
+			t.Errorf("expected variant %q in the cartesian product, got %v", want, variants)
+		}
+	}
+}
+
+func TestExpandMatrixRange(t *testing.T) {
+
+	options := []Spec{
+		{Package: mustIdent(t, "shard"), Range: "1-3"},
+	}
+
+	variants, err := ExpandMatrix(options, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var shards []string
+	for _, v := range variants {
+		shards = append(shards, v["shard"])
+	}
+	sort.Strings(shards)
+	if got, want := shards, []string{"1", "2", "3"}; !equalStrings(got, want) {
+		t.Errorf("expected shards %v, got %v", want, got)
+	}
+}
+
+func TestExpandMatrixExclude(t *testing.T) {
+
+	options := []Spec{
+		{Package: mustIdent(t, "python.abi"), Values: []string{"cp38", "cp39"}},
+		{Package: mustIdent(t, "static"), Values: []string{"true", "false"}},
+	}
+	exclude := []OptionMap{
+		{"python.abi": "cp38", "static": "true"},
+	}
+
+	variants, err := ExpandMatrix(options, exclude, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(variants) != 3 {
+		t.Fatalf("expected exclude to drop exactly 1 of 4 variants, got %d: %v", len(variants), variants)
+	}
+	for _, v := range variants {
+		if v["python.abi"] == "cp38" && v["static"] == "true" {
+			t.Errorf("excluded variant %v was not dropped", v)
+		}
+	}
+}
+
+func TestExpandMatrixInclude(t *testing.T) {
+
+	options := []Spec{
+		{Package: mustIdent(t, "python.abi"), Values: []string{"cp38", "cp39"}},
+	}
+	include := []OptionMap{
+		{"python.abi": "cp310", "static": "true"},
+	}
+
+	variants, err := ExpandMatrix(options, nil, include)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(variants) != 3 {
+		t.Fatalf("expected the 2 matrix variants plus 1 included variant, got %d: %v", len(variants), variants)
+	}
+
+	last := variants[len(variants)-1]
+	if last["python.abi"] != "cp310" || last["static"] != "true" {
+		t.Errorf("expected the included variant to be appended verbatim, got %v", last)
+	}
+}
+
+func TestExpandMatrixInvalidRange(t *testing.T) {
+
+	options := []Spec{
+		{Package: mustIdent(t, "shard"), Range: "3-1"},
+	}
+
+	_, err := ExpandMatrix(options, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for a range whose max is less than its min")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
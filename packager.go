@@ -0,0 +1,30 @@
+package spm
+
+import "fmt"
+
+// Packager commits the result of a build to a particular output format,
+// returning a Handle that can be used to locate the resulting artifact
+type Packager interface {
+	// Format returns the name this packager is registered under
+	Format() string
+	// Package commits the built file tree under root as an artifact of
+	// the given spec and variant
+	Package(spec *Spec, options OptionMap, root string) (Handle, error)
+}
+
+var packagers = make(map[string]Packager)
+
+// RegisterPackager makes a Packager available for use under the given
+// format name, overwriting any packager previously registered for it
+func RegisterPackager(format string, p Packager) {
+	packagers[format] = p
+}
+
+// Get looks up a previously registered Packager by its format name
+func Get(format string) (Packager, error) {
+	p, ok := packagers[format]
+	if !ok {
+		return nil, fmt.Errorf("no packager registered for format: %s", format)
+	}
+	return p, nil
+}
@@ -0,0 +1,88 @@
+package spm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+func init() {
+	RegisterPackager("rpm", &rpmPackager{})
+}
+
+// rpmPackager packages build results as an rpm by shelling out to the
+// system rpmbuild command, in the same spirit as internal/spfs wrapping
+// the spfs binary
+type rpmPackager struct{}
+
+func (p *rpmPackager) Format() string {
+	return "rpm"
+}
+
+func (p *rpmPackager) Package(spec *Spec, options OptionMap, root string) (Handle, error) {
+
+	release := spec.Package.Release.String()
+
+	specfile, err := p.writeRpmSpec(spec, release)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(specfile)
+
+	cmd := exec.Command(
+		"rpmbuild", "-bb",
+		"--buildroot", root,
+		"--define", "_rpmdir .",
+		specfile,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("rpmbuild failed: %w", err)
+	}
+
+	// matches the layout rpmbuild writes under --define _rpmdir .
+	outpath := filepath.Join("noarch", fmt.Sprintf("%s-%s-%s.noarch.rpm", spec.Package.Name, spec.Package.Version, release))
+	return NewFileHandle(spec, outpath), nil
+}
+
+// writeRpmSpec renders the given Spec's metadata as an rpmbuild spec
+// file and returns its path
+func (p *rpmPackager) writeRpmSpec(spec *Spec, release string) (string, error) {
+
+	var requires string
+	for _, dep := range spec.Depends {
+		requires += fmt.Sprintf("Requires: %s\n", dep.Package.Name)
+	}
+	var provides string
+	for _, prov := range spec.Provides {
+		provides += fmt.Sprintf("Provides: %s\n", prov.Package.Name)
+	}
+
+	content := fmt.Sprintf(`Name: %s
+Version: %s
+Release: %s
+Summary: %s
+License: unspecified
+BuildArch: noarch
+%s%s
+%%description
+Package built by spm
+
+%%files
+/*
+`, spec.Package.Name, spec.Package.Version, release, spec.Package.Name, requires, provides)
+
+	f, err := ioutil.TempFile("", "spm-*.spec")
+	if err != nil {
+		return "", fmt.Errorf("failed to create rpm spec file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		return "", fmt.Errorf("failed to write rpm spec file: %w", err)
+	}
+	return f.Name(), nil
+}
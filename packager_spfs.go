@@ -0,0 +1,31 @@
+package spm
+
+import (
+	"fmt"
+
+	"gitlab.spimageworks.com/dev-group/dev-ops/spm/internal/spfs"
+)
+
+func init() {
+	RegisterPackager("spfs", &spfsPackager{})
+}
+
+// spfsPackager commits build results directly into spfs, the original
+// and default output format for this module. It commits the given
+// root (the build's DESTDIR), the same install tree every other
+// packager archives, so one build.command works across all formats.
+type spfsPackager struct{}
+
+func (p *spfsPackager) Format() string {
+	return "spfs"
+}
+
+func (p *spfsPackager) Package(spec *Spec, options OptionMap, root string) (Handle, error) {
+
+	tag := "spm/pkg/" + spec.Package.String()
+	err := spfs.CommitLayerFiles(root, nil, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit package data to spfs: %w", err)
+	}
+	return NewSpFSHandle(spec, tag), nil
+}
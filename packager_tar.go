@@ -0,0 +1,75 @@
+package spm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	RegisterPackager("tar", &tarPackager{})
+}
+
+// tarPackager packages build results as a plain .tar.gz archive of the
+// built file tree
+type tarPackager struct{}
+
+func (p *tarPackager) Format() string {
+	return "tar"
+}
+
+func (p *tarPackager) Package(spec *Spec, options OptionMap, root string) (Handle, error) {
+
+	outpath := fmt.Sprintf("%s-%s-%s.tar.gz", spec.Package.Name, spec.Package.Version, spec.Package.Release)
+	out, err := os.Create(outpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tar archive: %w", err)
+	}
+	defer out.Close()
+
+	gzw := gzip.NewWriter(out)
+	defer gzw.Close()
+	tw := tar.NewWriter(gzw)
+	defer tw.Close()
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive build tree: %w", err)
+	}
+
+	return NewFileHandle(spec, outpath), nil
+}
@@ -0,0 +1,41 @@
+package spm
+
+import "testing"
+
+type fakePackager struct{ format string }
+
+func (p *fakePackager) Format() string { return p.format }
+
+func (p *fakePackager) Package(spec *Spec, options OptionMap, root string) (Handle, error) {
+	return NewFileHandle(spec, root), nil
+}
+
+func TestRegisterPackagerAndGet(t *testing.T) {
+
+	RegisterPackager("fake", &fakePackager{format: "fake"})
+
+	packager, err := Get("fake")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if packager.Format() != "fake" {
+		t.Errorf("expected the registered packager back, got format %q", packager.Format())
+	}
+}
+
+func TestGetUnknownFormat(t *testing.T) {
+
+	_, err := Get("no-such-format")
+	if err == nil {
+		t.Fatal("expected an error for an unregistered format")
+	}
+}
+
+func TestBuiltinFormatsAreRegistered(t *testing.T) {
+
+	for _, format := range []string{"spfs", "tar", "rpm"} {
+		if _, err := Get(format); err != nil {
+			t.Errorf("expected %q to be registered by its init(), got: %v", format, err)
+		}
+	}
+}
@@ -8,6 +8,28 @@ func ParseRelease(source string) Release {
 	return Release{source}
 }
 
+// ParseReleaseResolved computes a Release by invoking the given resolver
+// callback, for specs that derive their release rather than stating it
+// literally (eg. `version: {from: git}`)
+func ParseReleaseResolved(resolve func() (string, error)) (Release, error) {
+	source, err := resolve()
+	if err != nil {
+		return Release{}, err
+	}
+	return Release{source}, nil
+}
+
 func (release Release) String() string {
 	return release.source
 }
+
+// WithVariant folds a build variant's digest into this release, so that
+// distinct variants of the same package version commit to distinct
+// spfs tags. If the release is unset, the digest becomes the release
+// outright.
+func (release Release) WithVariant(digest string) Release {
+	if release.source == "" {
+		return Release{digest}
+	}
+	return Release{release.source + "." + digest}
+}
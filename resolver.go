@@ -0,0 +1,166 @@
+package spm
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.spimageworks.com/dev-group/dev-ops/spm/internal/spfs"
+)
+
+// PackageSource locates candidate specs that could satisfy a requested
+// package identifier
+type PackageSource interface {
+	// Candidates returns every known spec published under the given
+	// package name
+	Candidates(name string) ([]*Spec, error)
+}
+
+// manifestPath is where Build stores a copy of the effective spec (the
+// one with when-expressions already resolved) inside the main package
+// layer it commits, so that SpFSPackageSource can recover the real
+// depends and provides of a candidate instead of just its identifier.
+// Subpackage layers hold only the files their globs select.
+const manifestPath = "/spm/manifest.yaml"
+
+// SpFSPackageSource finds candidate specs by listing the tags committed
+// for a package under spm/pkg/<name> in spfs, then reading each
+// candidate's manifest back out of its layer to recover its real
+// depends/provides
+type SpFSPackageSource struct{}
+
+func (s SpFSPackageSource) Candidates(name string) ([]*Spec, error) {
+
+	tags, err := spfs.ListTags("spm/pkg/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list candidates for %s: %w", name, err)
+	}
+
+	candidates := make([]*Spec, 0, len(tags))
+	for _, tag := range tags {
+		ident, err := ParseIdent(strings.TrimPrefix(tag, "spm/pkg/"))
+		if err != nil {
+			continue
+		}
+
+		manifest, err := spfs.ReadFile(tag, manifestPath)
+		if err != nil {
+			// predates the manifest convention, or was committed by
+			// something other than spm; treat it as depends/provides-less
+			candidates = append(candidates, &Spec{Package: ident})
+			continue
+		}
+		spec, err := decodeSpec(string(manifest))
+		if err != nil {
+			continue
+		}
+		spec.Package = ident
+		candidates = append(candidates, spec)
+	}
+	return candidates, nil
+}
+
+// Plan is the result of resolving a set of requested packages
+type Plan struct {
+	// TransitiveDeps holds every resolved package that was pulled in to
+	// satisfy a request, excluding the requests themselves. The spec
+	// model has no build-time/runtime distinction, so this is not a
+	// "build-only" set; it is simply InstallDeps minus what was asked for.
+	TransitiveDeps []Ident
+	// InstallDeps is the full, topologically ordered set of packages
+	// (dependencies before dependents) needed to install and run the
+	// requested packages
+	InstallDeps []Ident
+}
+
+// Resolver turns a set of requested package identifiers into an
+// ordered Plan, using a PackageSource to look up candidate specs
+type Resolver struct {
+	Source PackageSource
+}
+
+// NewResolver creates a resolver backed by the given package source
+func NewResolver(source PackageSource) *Resolver {
+	return &Resolver{Source: source}
+}
+
+// Resolve computes an install Plan for the given set of requested
+// package identifiers, detecting dependency cycles and conflicting
+// Provides along the way
+func (r *Resolver) Resolve(requests []Ident) (*Plan, error) {
+
+	resolved := make(map[string]*Spec)
+	provided := make(map[string]string) // virtual name -> providing package name
+	order := make([]string, 0)
+	visiting := make(map[string]bool)
+
+	var visit func(ident Ident) error
+	visit = func(ident Ident) error {
+		if _, done := resolved[ident.Name]; done {
+			return nil
+		}
+		if visiting[ident.Name] {
+			return fmt.Errorf("dependency cycle detected at package: %s", ident.Name)
+		}
+		visiting[ident.Name] = true
+		defer delete(visiting, ident.Name)
+
+		candidates, err := r.Source.Candidates(ident.Name)
+		if err != nil {
+			return err
+		}
+
+		var chosen *Spec
+		for _, candidate := range candidates {
+			if ident.Satisfies(candidate.Package) {
+				chosen = candidate
+				break
+			}
+		}
+		if chosen == nil {
+			return fmt.Errorf("no package found to satisfy: %s", ident)
+		}
+
+		for _, provides := range chosen.Provides {
+			if owner, exists := provided[provides.Package.Name]; exists && owner != chosen.Package.Name {
+				return fmt.Errorf("conflict: both %s and %s provide %s", owner, chosen.Package.Name, provides.Package.Name)
+			}
+			provided[provides.Package.Name] = chosen.Package.Name
+		}
+
+		for _, dep := range chosen.Depends {
+			if err := visit(dep.Package); err != nil {
+				return err
+			}
+		}
+
+		resolved[ident.Name] = chosen
+		order = append(order, ident.Name)
+		return nil
+	}
+
+	for _, req := range requests {
+		if err := visit(req); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &Plan{
+		InstallDeps: make([]Ident, 0, len(order)),
+	}
+	for _, name := range order {
+		plan.InstallDeps = append(plan.InstallDeps, resolved[name].Package)
+	}
+
+	requested := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		requested[req.Name] = true
+	}
+	for _, name := range order {
+		if requested[name] {
+			continue
+		}
+		plan.TransitiveDeps = append(plan.TransitiveDeps, resolved[name].Package)
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,89 @@
+package spm
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakePackageSource resolves candidates from an in-memory table of specs,
+// keyed by package name, for exercising Resolver without spfs
+type fakePackageSource map[string][]*Spec
+
+func (f fakePackageSource) Candidates(name string) ([]*Spec, error) {
+	return f[name], nil
+}
+
+func mustIdent(t *testing.T, source string) Ident {
+	t.Helper()
+	ident, err := ParseIdent(source)
+	if err != nil {
+		t.Fatalf("failed to parse ident %q: %v", source, err)
+	}
+	return ident
+}
+
+func TestResolveTopologicalOrder(t *testing.T) {
+
+	source := fakePackageSource{
+		"app":  {{Package: mustIdent(t, "app/1.0.0"), Depends: []Spec{{Package: mustIdent(t, "lib")}}}},
+		"lib":  {{Package: mustIdent(t, "lib/1.0.0"), Depends: []Spec{{Package: mustIdent(t, "base")}}}},
+		"base": {{Package: mustIdent(t, "base/1.0.0")}},
+	}
+
+	resolver := NewResolver(source)
+	plan, err := resolver.Resolve([]Ident{mustIdent(t, "app")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var order []string
+	for _, ident := range plan.InstallDeps {
+		order = append(order, ident.Name)
+	}
+	got := strings.Join(order, ",")
+	want := "base,lib,app"
+	if got != want {
+		t.Errorf("expected dependencies before dependents: got %q, want %q", got, want)
+	}
+
+	if len(plan.TransitiveDeps) != 2 {
+		t.Errorf("expected 2 transitive dependencies, got %d: %v", len(plan.TransitiveDeps), plan.TransitiveDeps)
+	}
+}
+
+func TestResolveDetectsCycle(t *testing.T) {
+
+	source := fakePackageSource{
+		"a": {{Package: mustIdent(t, "a/1.0.0"), Depends: []Spec{{Package: mustIdent(t, "b")}}}},
+		"b": {{Package: mustIdent(t, "b/1.0.0"), Depends: []Spec{{Package: mustIdent(t, "a")}}}},
+	}
+
+	resolver := NewResolver(source)
+	_, err := resolver.Resolve([]Ident{mustIdent(t, "a")})
+	if err == nil {
+		t.Fatal("expected a dependency cycle error")
+	}
+}
+
+func TestResolveDetectsProvidesConflict(t *testing.T) {
+
+	source := fakePackageSource{
+		"a": {{Package: mustIdent(t, "a/1.0.0"), Provides: []Spec{{Package: mustIdent(t, "virtual")}}}},
+		"b": {{Package: mustIdent(t, "b/1.0.0"), Provides: []Spec{{Package: mustIdent(t, "virtual")}}}},
+	}
+
+	resolver := NewResolver(source)
+	_, err := resolver.Resolve([]Ident{mustIdent(t, "a"), mustIdent(t, "b")})
+	if err == nil {
+		t.Fatal("expected a conflicting provides error")
+	}
+}
+
+func TestResolveNoCandidate(t *testing.T) {
+
+	resolver := NewResolver(fakePackageSource{})
+	_, err := resolver.Resolve([]Ident{mustIdent(t, "missing")})
+	if err == nil {
+		t.Fatal("expected an error when no candidate satisfies a request")
+	}
+}
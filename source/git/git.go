@@ -0,0 +1,73 @@
+// Package git derives a package's version and release from a git
+// working tree, following the same conventions as buildinfo's git
+// parser: `git describe` for the version, and the current branch plus
+// commit for the release.
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DescribeVersion computes a version string for the git working tree
+// rooted at dir, using `git describe --tags --dirty --always`
+func DescribeVersion(dir string) (string, error) {
+
+	out, err := run(dir, "describe", "--tags", "--dirty", "--always")
+	if err != nil {
+		return "", fmt.Errorf("failed to compute version from git: %w", err)
+	}
+	return out, nil
+}
+
+// DescribeRelease computes a release string for the git working tree
+// rooted at dir, combining the current branch, the short commit hash,
+// and a "-dirty" suffix when the working tree has local modifications
+func DescribeRelease(dir string) (string, error) {
+
+	branch, err := run(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to determine git branch: %w", err)
+	}
+
+	commit, err := run(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to determine git commit: %w", err)
+	}
+
+	release := fmt.Sprintf("%s.%s", sanitizeBranch(branch), commit)
+
+	dirty, err := isDirty(dir)
+	if err != nil {
+		return "", err
+	}
+	if dirty {
+		release += "-dirty"
+	}
+	return release, nil
+}
+
+func isDirty(dir string) (bool, error) {
+
+	out, err := run(dir, "status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("failed to check git working tree status: %w", err)
+	}
+	return out != "", nil
+}
+
+func run(dir string, args ...string) (string, error) {
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func sanitizeBranch(branch string) string {
+	return strings.ReplaceAll(branch, "/", "-")
+}
@@ -0,0 +1,112 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeBranch(t *testing.T) {
+
+	cases := []struct {
+		branch string
+		want   string
+	}{
+		{"main", "main"},
+		{"feature/foo", "feature-foo"},
+		{"release/2024/q1", "release-2024-q1"},
+	}
+	for _, c := range cases {
+		if got := sanitizeBranch(c.branch); got != c.want {
+			t.Errorf("sanitizeBranch(%q): got %q, want %q", c.branch, got, c.want)
+		}
+	}
+}
+
+// initRepo creates a throwaway git repository with one commit and tag,
+// for exercising DescribeVersion/DescribeRelease against real git
+func initRepo(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "spm-git-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	run("init", "-b", "main")
+	run("config", "user.email", "spm@example.com")
+	run("config", "user.name", "spm")
+	if err := ioutil.WriteFile(dir+"/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	run("add", "file.txt")
+	run("commit", "-m", "initial")
+	run("tag", "v1.0.0")
+
+	return dir
+}
+
+func TestDescribeVersion(t *testing.T) {
+
+	dir := initRepo(t)
+
+	version, err := DescribeVersion(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != "v1.0.0" {
+		t.Errorf("expected version v1.0.0, got %s", version)
+	}
+}
+
+func TestDescribeReleaseCleanAndDirty(t *testing.T) {
+
+	dir := initRepo(t)
+
+	release, err := DescribeRelease(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(release, "main.") {
+		t.Errorf("expected release to start with the sanitized branch name, got %s", release)
+	}
+	if strings.HasSuffix(release, "-dirty") {
+		t.Errorf("expected a clean working tree to not be marked dirty, got %s", release)
+	}
+
+	if err := ioutil.WriteFile(dir+"/file.txt", []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+
+	dirtyRelease, err := DescribeRelease(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(dirtyRelease, "-dirty") {
+		t.Errorf("expected a modified working tree to be marked dirty, got %s", dirtyRelease)
+	}
+}
+
+func TestDescribeVersionNotARepo(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "spm-notgit-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := DescribeVersion(dir); err == nil {
+		t.Fatal("expected an error describing a non-git directory")
+	}
+}
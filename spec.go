@@ -25,6 +25,38 @@ type Spec struct {
 	Options  []Spec    `yaml:"opts"`
 	Depends  []Spec    `yaml:"depends"`
 	Provides []Spec    `yaml:"provides"`
+	// When is an expr expression over the current build options. When
+	// set, a Depends or Provides entry is only included if it evaluates
+	// to true. See package spm/expr.
+	When string `yaml:"when"`
+	// Values lists the values this option may take when automatically
+	// expanding a build matrix from build.matrix. Ignored elsewhere.
+	Values []string `yaml:"values"`
+	// Range is shorthand for Values as an inclusive integer range, eg.
+	// "1-3" expands to ["1", "2", "3"].
+	Range string `yaml:"range"`
+	// Subpackages splits the result of this spec's single source build
+	// into one or more binary packages, each with its own identity,
+	// dependencies and file selection against the built tree.
+	Subpackages []Subpackage `yaml:"subpackages"`
+	// Version, when set, derives Package.Version/Release from an
+	// external source instead of requiring them literally in the pkg
+	// field, eg. `version: {from: git}`. See resolveVersion.
+	Version *VersionSpec `yaml:"version"`
+}
+
+// VersionSpec configures how a spec's version/release are derived
+type VersionSpec struct {
+	From string `yaml:"from"`
+}
+
+// Subpackage describes a binary package split out of a single source
+// build, selecting its files via glob patterns against the built tree
+type Subpackage struct {
+	Package  Ident    `yaml:"pkg"`
+	Files    []string `yaml:"files"`
+	Depends  []Spec   `yaml:"depends"`
+	Provides []Spec   `yaml:"provides"`
 }
 
 // ReadSpec loads a package specification from a yaml file
@@ -41,6 +73,26 @@ func ReadSpec(filepath string) (*Spec, error) {
 // a package specification
 func ParseSpec(source string) (*Spec, error) {
 
+	def, err := decodeSpec(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resolveVersion(def); err != nil {
+		return nil, fmt.Errorf("Failed to resolve package version: %w", err)
+	}
+
+	return def, nil
+
+}
+
+// decodeSpec parses the raw yaml string of a package specification
+// without resolving its Version source. Used by ParseSpec, and by
+// callers such as SpFSPackageSource that already know a candidate's
+// concrete version/release from its spfs tag and only need the rest
+// of the spec (depends, provides, ...).
+func decodeSpec(source string) (*Spec, error) {
+
 	def := new(Spec)
 	dec := yaml.NewDecoder(bytes.NewReader([]byte(source)))
 	dec.SetStrict(true)
@@ -49,7 +101,6 @@ func ParseSpec(source string) (*Spec, error) {
 		return nil, fmt.Errorf("Failed to read specification: %w", err)
 	}
 	return def, nil
-
 }
 
 // Ident represents a package identifier
@@ -99,6 +150,22 @@ func (spec Ident) String() string {
 	return specString
 }
 
+// Satisfies reports whether the given candidate identifier meets this
+// identifier's constraints. An unset Version or Release on the receiver
+// is treated as unconstrained and matches any candidate.
+func (spec Ident) Satisfies(candidate Ident) bool {
+	if spec.Name != candidate.Name {
+		return false
+	}
+	if spec.Version.String() != "" && spec.Version.String() != candidate.Version.String() {
+		return false
+	}
+	if spec.Release.String() != "" && spec.Release.String() != candidate.Release.String() {
+		return false
+	}
+	return true
+}
+
 // MarshalYAML turns this package spec into a yaml string
 func (spec Ident) MarshalYAML() (interface{}, error) {
 	return spec.String(), nil
@@ -121,6 +188,29 @@ type BuildSpec struct {
 	Command  string      `yaml:"command"`
 	Options  []OptionMap `yaml:"opts"`
 	Variants []OptionMap `yaml:"variants"`
+	// Formats lists the output packager formats (eg. "spfs", "tar", "rpm")
+	// that each variant should be packaged as. Defaults to "spfs".
+	Formats []string `yaml:"formats"`
+	// Env lists environment variables to set for the build command,
+	// each optionally gated by a When expression.
+	Env []EnvVar `yaml:"env"`
+	// Matrix, when true, computes Variants as the cartesian product of
+	// each Options entry's Values/Range instead of requiring them to be
+	// enumerated by hand. Ignored if Variants is set.
+	Matrix bool `yaml:"matrix"`
+	// Exclude drops any matrix-computed variant matching every key/value
+	// pair of one of these partial overlays, GitHub Actions matrix-style.
+	Exclude []OptionMap `yaml:"exclude"`
+	// Include appends these variants verbatim after matrix expansion.
+	Include []OptionMap `yaml:"include"`
+}
+
+// EnvVar is a single environment variable to set for the build command,
+// optionally gated by a When expression over the build options
+type EnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+	When  string `yaml:"when"`
 }
 
 // OptionMap is a set of values for package build options
@@ -137,7 +227,11 @@ func (om OptionMap) OrderedKeys() []string {
 
 }
 
-func (om OptionMap) Digest() string {
+// Digest returns a stable hash of this option map. Any extra values
+// passed in (eg. the resolved dependency identifiers for a variant) are
+// folded into the hash as well, so that variants with the same options
+// but different effective dependencies still hash differently.
+func (om OptionMap) Digest(extra ...string) string {
 
 	hasher := sha1.New()
 	for _, name := range om.OrderedKeys() {
@@ -146,6 +240,14 @@ func (om OptionMap) Digest() string {
 		hasher.Write([]byte(om[name]))
 		hasher.Write([]byte{0})
 	}
+
+	sorted := append([]string(nil), extra...)
+	sort.Strings(sorted)
+	for _, e := range sorted {
+		hasher.Write([]byte(e))
+		hasher.Write([]byte{0})
+	}
+
 	return base32.StdEncoding.EncodeToString(hasher.Sum(nil))[:digestSize]
 
 }
@@ -8,6 +8,17 @@ func ParseVersion(source string) Version {
 	return Version{source}
 }
 
+// ParseVersionResolved computes a Version by invoking the given resolver
+// callback, for specs that derive their version rather than stating it
+// literally (eg. `version: {from: git}`)
+func ParseVersionResolved(resolve func() (string, error)) (Version, error) {
+	source, err := resolve()
+	if err != nil {
+		return Version{}, err
+	}
+	return Version{source}, nil
+}
+
 func (version Version) String() string {
 	return version.source
 }
@@ -0,0 +1,64 @@
+package spm
+
+import (
+	"fmt"
+	"os"
+
+	"gitlab.spimageworks.com/dev-group/dev-ops/spm/source/git"
+)
+
+// resolveVersion fills in spec.Package.Version/Release from the source
+// configured in spec.Version (if any), so that downstream Ident.String()
+// and OptionMap.Digest() calls see fully-resolved values. SPM_VERSION and
+// SPM_RELEASE always take precedence, for reproducible CI builds.
+func resolveVersion(spec *Spec) error {
+
+	if spec.Version != nil {
+		switch spec.Version.From {
+		case "git":
+			dir, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to resolve working directory: %w", err)
+			}
+			version, release, err := versionFromGit(dir)
+			if err != nil {
+				return err
+			}
+			spec.Package.Version = version
+			spec.Package.Release = release
+		default:
+			return fmt.Errorf("unknown version source: %q", spec.Version.From)
+		}
+	}
+
+	if override, ok := os.LookupEnv("SPM_VERSION"); ok {
+		spec.Package.Version = ParseVersion(override)
+	}
+	if override, ok := os.LookupEnv("SPM_RELEASE"); ok {
+		spec.Package.Release = ParseRelease(override)
+	}
+
+	return nil
+}
+
+// versionFromGit derives a version/release pair from the git working
+// tree rooted at dir, falling back to a placeholder pair when the
+// checkout isn't a git repository at all
+func versionFromGit(dir string) (Version, Release, error) {
+
+	version, err := ParseVersionResolved(func() (string, error) {
+		return git.DescribeVersion(dir)
+	})
+	if err != nil {
+		return ParseVersion("0.0.0"), ParseRelease("nogit"), nil
+	}
+
+	release, err := ParseReleaseResolved(func() (string, error) {
+		return git.DescribeRelease(dir)
+	})
+	if err != nil {
+		return version, ParseRelease("nogit"), nil
+	}
+
+	return version, release, nil
+}
@@ -0,0 +1,55 @@
+package spm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVersionFromGitFallsBackWithoutRepo(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "spm-nogit-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	version, release, err := versionFromGit(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version.String() != "0.0.0" {
+		t.Errorf("expected fallback version 0.0.0, got %s", version)
+	}
+	if release.String() != "nogit" {
+		t.Errorf("expected fallback release nogit, got %s", release)
+	}
+}
+
+func TestResolveVersionUnknownSource(t *testing.T) {
+
+	spec := &Spec{Version: &VersionSpec{From: "svn"}}
+	err := resolveVersion(spec)
+	if err == nil {
+		t.Fatal("expected an error for an unknown version source")
+	}
+}
+
+func TestResolveVersionEnvOverride(t *testing.T) {
+
+	os.Setenv("SPM_VERSION", "9.9.9")
+	os.Setenv("SPM_RELEASE", "r9")
+	defer os.Unsetenv("SPM_VERSION")
+	defer os.Unsetenv("SPM_RELEASE")
+
+	spec := &Spec{}
+	if err := resolveVersion(spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec.Package.Version.String() != "9.9.9" {
+		t.Errorf("expected SPM_VERSION to override, got %s", spec.Package.Version)
+	}
+	if spec.Package.Release.String() != "r9" {
+		t.Errorf("expected SPM_RELEASE to override, got %s", spec.Package.Release)
+	}
+}